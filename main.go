@@ -2,10 +2,37 @@ package main
 
 import (
 	"client-go-test/pkg"
-	"k8s.io/client-go/informers"
+	"context"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog/v2"
+	"net/http"
+	"os"
+	"time"
+)
+
+// metricsAddr is where /metrics and /healthz are served for Prometheus
+// scraping and liveness/readiness probes.
+var metricsAddr = ":8080"
+
+// namespaces lists the namespaces the controller watches. Use
+// []string{metav1.NamespaceAll} instead to watch the whole cluster with a
+// single factory.
+var namespaces = []string{"default"}
+
+// ingressClassName, if set, restricts management to Services requesting this
+// class (or no class at all). Empty means "manage everything".
+var ingressClassName = ""
+
+const (
+	leaseLockName      = "ingress-auto-controller-lock"
+	leaseLockNamespace = "default"
 )
 
 //func main() {
@@ -60,13 +87,65 @@ func main() {
 		panic(err)
 	}
 
-	factory := informers.NewSharedInformerFactoryWithOptions(clientset, 0, informers.WithNamespace("default"))
-	serviceInformer := factory.Core().V1().Services()
-	ingressInformer := factory.Networking().V1().Ingresses()
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		panic(err)
+	}
 
-	controller := pkg.NewController(clientset, serviceInformer, ingressInformer)
+	controller := pkg.NewController(clientset, dynamicClient, namespaces, nil, ingressClassName)
 	stopCh := make(chan struct{})
-	factory.Start(stopCh)
-	factory.WaitForCacheSync(stopCh)
-	controller.Run(stopCh)
+	controller.StartInformers(stopCh)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	go func() {
+		if err := http.ListenAndServe(metricsAddr, mux); err != nil {
+			klog.ErrorS(err, "metrics server exited")
+		}
+	}()
+
+	id, err := os.Hostname()
+	if err != nil {
+		panic(err)
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: v1.ObjectMeta{
+			Name:      leaseLockName,
+			Namespace: leaseLockNamespace,
+		},
+		Client: clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: id,
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		ReleaseOnCancel: true,
+		LeaseDuration:   15 * time.Second,
+		RenewDeadline:   10 * time.Second,
+		RetryPeriod:     2 * time.Second,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				klog.InfoS("became leader, processing workqueue", "identity", id)
+				controller.RunWorkers(ctx.Done())
+			},
+			OnStoppedLeading: func() {
+				klog.InfoS("lost leadership, stepping down", "identity", id)
+			},
+			OnNewLeader: func(identity string) {
+				if identity == id {
+					return
+				}
+				klog.InfoS("new leader elected", "identity", identity)
+			},
+		},
+	})
 }
@@ -0,0 +1,18 @@
+package v1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	GroupName = "expose.example.com"
+	Version   = "v1"
+	Resource  = "exposepolicies"
+	Kind      = "ExposePolicy"
+)
+
+// SchemeGroupVersion is group version used to register these objects.
+var SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: Version}
+
+// GroupVersionResource is the GVR the controller's dynamic informer watches.
+var GroupVersionResource = SchemeGroupVersion.WithResource(Resource)
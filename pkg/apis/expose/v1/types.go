@@ -0,0 +1,34 @@
+// Package v1 contains the expose.example.com/v1 ExposePolicy type: a CRD
+// that lets operators declare "these Services should be exposed like this"
+// by label selector instead of annotating each Service individually.
+package v1
+
+import (
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ExposePolicySpec selects Services by label and declares how the matching
+// ones should be exposed. A Service's own ingress/* annotations take
+// precedence over these fields; any field left empty here, with no
+// overriding annotation, falls back to the controller's built-in default.
+type ExposePolicySpec struct {
+	// Selector matches Services in the policy's namespace by label, the same
+	// way a Service matches Pods.
+	Selector map[string]string `json:"selector"`
+
+	Host             string `json:"host,omitempty"`
+	Path             string `json:"path,omitempty"`
+	PathType         string `json:"pathType,omitempty"`
+	TLSSecret        string `json:"tlsSecret,omitempty"`
+	IngressClassName string `json:"ingressClassName,omitempty"`
+}
+
+// ExposePolicy is the expose.example.com/v1 CRD. The controller watches it
+// via a dynamic informer (see pkg.NewController) and converts instances to
+// this type on demand with runtime.DefaultUnstructuredConverter.
+type ExposePolicy struct {
+	v1.TypeMeta   `json:",inline"`
+	v1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ExposePolicySpec `json:"spec"`
+}
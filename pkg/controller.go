@@ -1,83 +1,317 @@
 package pkg
 
 import (
+	exposev1 "client-go-test/pkg/apis/expose/v1"
 	"context"
 	coreV1 "k8s.io/api/core/v1"
 	networkingV1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
-	informerCoreV1 "k8s.io/client-go/informers/core/v1"
-	informernetworkingv1 "k8s.io/client-go/informers/networking/v1"
+	metav1ac "k8s.io/client-go/applyconfigurations/meta/v1"
+	networkingv1ac "k8s.io/client-go/applyconfigurations/networking/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	corev1 "k8s.io/client-go/listers/core/v1"
 	networkingv1 "k8s.io/client-go/listers/networking/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
 	"reflect"
+	"strconv"
+	"strings"
 	"time"
 )
 
 const (
 	workNum  = 5
 	maxRetry = 10
+
+	// fieldManager identifies this controller's ownership in server-side
+	// apply so it only ever claims the fields it sets, letting humans edit
+	// the rest of the Ingress (e.g. other annotations) without fighting it.
+	fieldManager = "ingress-auto-controller"
+)
+
+// Annotations recognised on a Service to drive the generated Ingress.
+// ingressHTTPAnnotation is the trigger: its presence (any value) tells the
+// controller to manage an Ingress for the Service at all. The rest are
+// optional overrides of the Ingress template; anything under
+// ingressAnnotationPrefix is copied verbatim onto the generated Ingress's
+// annotations so nginx/traefik-style directives can be set from the Service.
+const (
+	ingressHTTPAnnotation      = "ingress/http"
+	ingressHostAnnotation      = "ingress/host"
+	ingressPathAnnotation      = "ingress/path"
+	ingressPathTypeAnnotation  = "ingress/path-type"
+	ingressPortAnnotation      = "ingress/port"
+	ingressTLSSecretAnnotation = "ingress/tls-secret"
+	ingressClassAnnotation     = "ingress/class"
+	ingressAnnotationPrefix    = "ingress.annotation/"
+
+	defaultIngressHost = "example.com"
+	defaultIngressPath = "/"
+	defaultIngressPort = int32(80)
 )
 
 type controller struct {
-	client        kubernetes.Interface
-	ingressLister networkingv1.IngressLister
-	serviceLister corev1.ServiceLister
-	queue         workqueue.RateLimitingInterface
+	client           kubernetes.Interface
+	factories        []informers.SharedInformerFactory
+	dynamicFactories []dynamicinformer.DynamicSharedInformerFactory
+	ingressListers   map[string]networkingv1.IngressLister
+	serviceListers   map[string]corev1.ServiceLister
+	policyListers    map[string]cache.GenericLister
+	queue            workqueue.RateLimitingInterface
+	ingressClassName string
+}
+
+// NewController builds one SharedInformerFactory per entry in namespaces,
+// fanning their Service/Ingress events into a single workqueue keyed by
+// "namespace/name". Pass []string{metav1.NamespaceAll} to watch the whole
+// cluster with one factory instead of one per namespace. tweakListOptions is
+// forwarded to informers.WithTweakListOptions on every factory so callers can
+// narrow the watch with a label/field selector; it may be nil.
+//
+// dynamicClient drives a second, dynamic informer per namespace over
+// exposev1.GroupVersionResource (the ExposePolicy CRD); no generated typed
+// client exists for it yet, so policies are read as *unstructured.Unstructured
+// and converted on demand with runtime.DefaultUnstructuredConverter.
+//
+// ingressClassName, if non-empty, restricts management to Services that
+// either request it explicitly (via the ingressClassAnnotation or a matching
+// ExposePolicy's ingressClassName) or don't request any class at all; it's
+// also used as the default
+// spec.IngressClassName on created Ingresses when neither the annotation nor
+// a matching ExposePolicy set one.
+// Leave it empty to manage every Service regardless of class.
+func NewController(client kubernetes.Interface, dynamicClient dynamic.Interface, namespaces []string, tweakListOptions func(options *v1.ListOptions), ingressClassName string) controller {
+	c := controller{
+		client:           client,
+		factories:        make([]informers.SharedInformerFactory, 0, len(namespaces)),
+		dynamicFactories: make([]dynamicinformer.DynamicSharedInformerFactory, 0, len(namespaces)),
+		serviceListers:   map[string]corev1.ServiceLister{},
+		ingressListers:   map[string]networkingv1.IngressLister{},
+		policyListers:    map[string]cache.GenericLister{},
+		queue:            workqueue.NewRateLimitingQueueWithConfig(workqueue.DefaultControllerRateLimiter(), workqueue.RateLimitingQueueConfig{Name: "ingress-auto-controller"}),
+		ingressClassName: ingressClassName,
+	}
+
+	for _, ns := range namespaces {
+		opts := []informers.SharedInformerOption{informers.WithNamespace(ns)}
+		if tweakListOptions != nil {
+			opts = append(opts, informers.WithTweakListOptions(tweakListOptions))
+		}
+		factory := informers.NewSharedInformerFactoryWithOptions(client, 0, opts...)
+
+		serviceInformer := factory.Core().V1().Services()
+		ingressInformer := factory.Networking().V1().Ingresses()
+		c.serviceListers[ns] = serviceInformer.Lister()
+		c.ingressListers[ns] = ingressInformer.Lister()
+
+		serviceInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    c.addService,
+			UpdateFunc: c.updateService,
+		})
+
+		ingressInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			DeleteFunc: c.deleteIngress,
+		})
+
+		c.factories = append(c.factories, factory)
+
+		dynamicFactory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, 0, ns, tweakListOptions)
+		policyInformer := dynamicFactory.ForResource(exposev1.GroupVersionResource)
+		c.policyListers[ns] = policyInformer.Lister()
+
+		policyInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    c.enqueueServicesForPolicy,
+			UpdateFunc: func(_, newObj interface{}) { c.enqueueServicesForPolicy(newObj) },
+			DeleteFunc: c.enqueueServicesForPolicy,
+		})
+
+		c.dynamicFactories = append(c.dynamicFactories, dynamicFactory)
+	}
+
+	return c
 }
 
+// Run starts the informers and processes the workqueue until stopChan is
+// closed. Use StartInformers/RunWorkers separately under leader election, so
+// non-leader replicas can keep their caches warm without touching the
+// workqueue.
 func (c *controller) Run(stopChan chan struct{}) {
+	c.StartInformers(stopChan)
+	c.RunWorkers(stopChan)
+}
+
+// StartInformers starts every factory and blocks until all of their caches
+// have synced. Safe to call on a non-leader replica to keep it warm.
+func (c *controller) StartInformers(stopCh <-chan struct{}) {
+	for _, factory := range c.factories {
+		factory.Start(stopCh)
+	}
+	for _, factory := range c.dynamicFactories {
+		factory.Start(stopCh)
+	}
+	for _, factory := range c.factories {
+		factory.WaitForCacheSync(stopCh)
+	}
+	for _, factory := range c.dynamicFactories {
+		factory.WaitForCacheSync(stopCh)
+	}
+}
+
+// RunWorkers starts the workers that drain the workqueue and blocks until
+// stopCh is closed. Only the elected leader should call this.
+//
+// stopCh closing also shuts the workqueue down: Get() on a shut-down queue
+// returns immediately with shutDown=true, which is what makes worker()'s
+// "for c.processNextItem() {}" loop actually exit. Without this, losing
+// leadership would leave the already-spawned workers draining and
+// reconciling forever, racing the newly-elected replica's own workers
+// against the same Ingresses.
+func (c *controller) RunWorkers(stopCh <-chan struct{}) {
+	go func() {
+		<-stopCh
+		c.queue.ShutDown()
+	}()
+
 	for i := 0; i < workNum; i++ {
-		go wait.Until(c.worker, time.Minute, stopChan)
+		go wait.Until(c.worker, time.Minute, stopCh)
 	}
-	<-stopChan
+	<-stopCh
 }
 
-func NewController(client kubernetes.Interface, serviceInformer informerCoreV1.ServiceInformer, ingressInformer informernetworkingv1.IngressInformer) controller {
-	c := controller{
-		client:        client,
-		serviceLister: serviceInformer.Lister(),
-		ingressLister: ingressInformer.Lister(),
-		queue:         workqueue.NewRateLimitingQueueWithConfig(workqueue.DefaultControllerRateLimiter(), workqueue.RateLimitingQueueConfig{}),
+// serviceLister returns the lister covering namespace, falling back to the
+// cluster-wide (metav1.NamespaceAll) lister when the controller was built
+// with a single all-namespaces factory instead of one per namespace.
+func (c *controller) serviceLister(namespace string) corev1.ServiceLister {
+	if l, ok := c.serviceListers[namespace]; ok {
+		return l
+	}
+	return c.serviceListers[v1.NamespaceAll]
+}
+
+func (c *controller) ingressLister(namespace string) networkingv1.IngressLister {
+	if l, ok := c.ingressListers[namespace]; ok {
+		return l
 	}
+	return c.ingressListers[v1.NamespaceAll]
+}
 
-	serviceInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    c.addService,
-		UpdateFunc: c.updateService,
-	})
+func (c *controller) policyLister(namespace string) cache.GenericLister {
+	if l, ok := c.policyListers[namespace]; ok {
+		return l
+	}
+	return c.policyListers[v1.NamespaceAll]
+}
 
-	ingressInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
-		DeleteFunc: c.deleteIngress,
-	})
+// enqueueServicesForPolicy runs whenever an ExposePolicy is added, updated or
+// deleted: it re-enqueues every Service in the policy's namespace currently
+// matching its selector so syncService picks up the new/old desired state.
+func (c *controller) enqueueServicesForPolicy(obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
 
-	return c
+	policy, err := toExposePolicy(u)
+	if err != nil {
+		klog.ErrorS(err, "failed to convert ExposePolicy from unstructured")
+		return
+	}
+
+	if len(policy.Spec.Selector) == 0 {
+		// An empty selector matches every Service in the namespace; treat it
+		// as misconfigured rather than auto-exposing everything.
+		klog.ErrorS(nil, "ExposePolicy has an empty selector, ignoring", "namespace", policy.Namespace, "name", policy.Name)
+		return
+	}
+
+	services, err := c.serviceLister(policy.Namespace).Services(policy.Namespace).List(labels.SelectorFromSet(policy.Spec.Selector))
+	if err != nil {
+		klog.ErrorS(err, "failed to list services for ExposePolicy", "namespace", policy.Namespace, "name", policy.Name)
+		return
+	}
+
+	for _, service := range services {
+		c.enqueue(service)
+	}
+}
+
+// matchingPolicy returns the first ExposePolicy in service's namespace whose
+// selector matches it, or nil if none do.
+func (c *controller) matchingPolicy(service *coreV1.Service) *exposev1.ExposePolicy {
+	lister := c.policyLister(service.Namespace)
+	if lister == nil {
+		return nil
+	}
+
+	objs, err := lister.ByNamespace(service.Namespace).List(labels.Everything())
+	if err != nil {
+		klog.ErrorS(err, "failed to list ExposePolicies", "namespace", service.Namespace)
+		return nil
+	}
+
+	for _, obj := range objs {
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+
+		policy, err := toExposePolicy(u)
+		if err != nil {
+			klog.ErrorS(err, "failed to convert ExposePolicy from unstructured")
+			continue
+		}
+
+		if len(policy.Spec.Selector) == 0 {
+			// An empty selector matches every Service in the namespace; treat
+			// it as misconfigured rather than auto-exposing everything.
+			continue
+		}
+
+		if labels.SelectorFromSet(policy.Spec.Selector).Matches(labels.Set(service.Labels)) {
+			return policy
+		}
+	}
+
+	return nil
+}
+
+func toExposePolicy(u *unstructured.Unstructured) (*exposev1.ExposePolicy, error) {
+	var policy exposev1.ExposePolicy
+	if err := apiruntime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
 }
 
 func (c *controller) enqueue(obj interface{}) {
-	//c.queue.Add(obj)
 	key, err := cache.MetaNamespaceKeyFunc(obj)
 	if err != nil {
-		runtime.HandleError(err)
+		klog.ErrorS(err, "failed to build workqueue key for object")
+		return
 	}
 
 	c.queue.Add(key)
 }
 
-// 当service有annotation为"ingress/http:true"时，创建ingress
-// 没有则忽略
+// 当service包含"ingress/http" annotation或被某个ExposePolicy选中时，创建ingress
+// 都没有则忽略
 func (c *controller) addService(obj interface{}) {
 	c.enqueue(obj)
 }
 
-// 当service包含指定的annotation，检查资源是否存在，不存在就创建ingress，存在则忽略
-// 不包含指定的annotation，检查ingress对象是否存在，存在则删除，不存在则忽略
+// 当service包含"ingress/http" annotation或被某个ExposePolicy选中，检查ingress是否存在：不存在就创建，
+// 存在则按当前annotation/policy（host/path/tls/class等）对比并reconcile
+// 两者都没有时，检查ingress对象是否存在且由该service控制，是则删除，否则忽略
 func (c *controller) updateService(oldObj, newObj interface{}) {
-	// todo 比较annotation
 	_, ok := newObj.(*coreV1.Service)
 	if !ok {
 		return
@@ -129,71 +363,179 @@ func (c *controller) processNextItem() bool {
 func (c *controller) syncService(key string) (err error) {
 	nameSpaceKey, name, err := cache.SplitMetaNamespaceKey(key)
 	if err != nil {
+		syncErrorsTotal.WithLabelValues("split-key").Inc()
 		return
 	}
 
 	// 删除
-	service, err := c.serviceLister.Services(nameSpaceKey).Get(name)
+	service, err := c.serviceLister(nameSpaceKey).Services(nameSpaceKey).Get(name)
 	if errors.IsNotFound(err) {
 		return nil
 	}
+	if err != nil {
+		syncErrorsTotal.WithLabelValues("get-service").Inc()
+		return
+	}
 
-	// 新增和删除
-	_, ok := service.GetAnnotations()["ingress/http"]
-	ingress, err := c.ingressLister.Ingresses(nameSpaceKey).Get(name)
+	// 新增、删除和更新
+	_, wantsIngress := service.GetAnnotations()[ingressHTTPAnnotation]
+	policy := c.matchingPolicy(service)
+	wantsAny := wantsIngress || policy != nil
+	ok := wantsAny && c.classMatches(service, policy)
+	ingress, err := c.ingressLister(nameSpaceKey).Ingresses(nameSpaceKey).Get(name)
 	if err != nil && !errors.IsNotFound(err) {
+		syncErrorsTotal.WithLabelValues("get-ingress").Inc()
 		return
 	}
+	ingressMissing := errors.IsNotFound(err)
+
+	if ok {
+		// create or reconcile: compare the desired spec/annotations against
+		// the lister cache first so an unchanged Ingress doesn't cost an
+		// apply call on every resync.
+		desired := c.constructIngress(service, policy)
+		if !ingressMissing && reflect.DeepEqual(ingress.Spec, desired.Spec) && reflect.DeepEqual(ingress.Annotations, desired.Annotations) {
+			return nil
+		}
 
-	if ok && errors.IsNotFound(err) {
-		// create ingress
-		ig := c.constructIngress(service)
-		_, err = c.client.NetworkingV1().Ingresses(nameSpaceKey).Create(context.TODO(), ig, v1.CreateOptions{})
+		applyConfig := c.constructIngressApplyConfiguration(service, policy)
+		_, err = c.client.NetworkingV1().Ingresses(nameSpaceKey).Apply(context.TODO(), applyConfig, v1.ApplyOptions{FieldManager: fieldManager, Force: true})
 		if err != nil {
+			syncErrorsTotal.WithLabelValues("apply").Inc()
 			return
 		}
-	} else if !ok && ingress != nil {
-		// delete ingress
+		if ingressMissing {
+			ingressCreatedTotal.Inc()
+		}
+	} else if !wantsAny && ingress != nil {
+		// delete ingress: a class mismatch (wantsAny true, ok false) is a
+		// different ingressClassName instance's Ingress to manage, not ours
+		// to delete, so it never reaches this branch. Double-check
+		// ownership too, so we never touch an Ingress this controller
+		// didn't create for this Service.
+		owner := v1.GetControllerOf(ingress)
+		if owner == nil || owner.Kind != "Service" || owner.UID != service.UID {
+			return nil
+		}
+
 		err = c.client.NetworkingV1().Ingresses(nameSpaceKey).Delete(context.TODO(), name, v1.DeleteOptions{})
 		if err != nil {
+			syncErrorsTotal.WithLabelValues("delete").Inc()
 			return
 		}
+		ingressDeletedTotal.Inc()
 	}
 	return nil
 }
 
 func (c *controller) handlerError(key string, err error) {
-	if c.queue.NumRequeues(key) <= maxRetry {
+	namespace, name, splitErr := cache.SplitMetaNamespaceKey(key)
+	retries := c.queue.NumRequeues(key)
+	if splitErr != nil {
+		klog.ErrorS(err, "syncService failed", "key", key, "retry", retries)
+	} else {
+		klog.ErrorS(err, "syncService failed", "key", key, "namespace", namespace, "name", name, "retry", retries)
+	}
+
+	if retries < maxRetry {
 		c.queue.AddRateLimited(key)
+		return
 	}
 
-	runtime.HandleError(err)
+	// exhausted retries: stop tracking this key so a future, unrelated
+	// event can start its rate limiting from scratch
 	c.queue.Forget(key)
 }
 
-func (c *controller) constructIngress(service *coreV1.Service) *networkingV1.Ingress {
+// ingressFields is the resolved, annotation/policy/default-precedence Ingress
+// template for a Service, shared by constructIngress (used for the
+// lister-cache diff) and constructIngressApplyConfiguration (used for the
+// actual server-side apply).
+type ingressFields struct {
+	host      string
+	path      string
+	pathType  networkingV1.PathType
+	port      int32
+	class     string
+	tlsSecret string
+}
+
+// resolveIngressFields resolves the Ingress template for service, with
+// annotations taking precedence over a matching ExposePolicy (policy may be
+// nil), falling back to the controller's built-in defaults.
+func (c *controller) resolveIngressFields(service *coreV1.Service, policy *exposev1.ExposePolicy) ingressFields {
+	annotations := service.GetAnnotations()
+
+	f := ingressFields{
+		host:     defaultIngressHost,
+		path:     defaultIngressPath,
+		pathType: networkingV1.PathTypePrefix,
+		port:     defaultIngressPort,
+		class:    c.resolvedClass(service, policy),
+	}
+
+	if policy != nil {
+		if policy.Spec.Host != "" {
+			f.host = policy.Spec.Host
+		}
+		if policy.Spec.Path != "" {
+			f.path = policy.Spec.Path
+		}
+		if policy.Spec.PathType != "" {
+			f.pathType = networkingV1.PathType(policy.Spec.PathType)
+		}
+		f.tlsSecret = policy.Spec.TLSSecret
+	}
+
+	if v, ok := annotations[ingressHostAnnotation]; ok && v != "" {
+		f.host = v
+	}
+	if v, ok := annotations[ingressPathAnnotation]; ok && v != "" {
+		f.path = v
+	}
+	if v, ok := annotations[ingressPathTypeAnnotation]; ok && v != "" {
+		f.pathType = networkingV1.PathType(v)
+	}
+	if v, ok := annotations[ingressPortAnnotation]; ok {
+		if p, err := strconv.Atoi(v); err == nil {
+			f.port = int32(p)
+		}
+	}
+	if v, ok := annotations[ingressTLSSecretAnnotation]; ok && v != "" {
+		f.tlsSecret = v
+	}
+
+	return f
+}
+
+// constructIngress builds the desired Ingress for service, used to diff
+// against the lister cache before reconciling.
+func (c *controller) constructIngress(service *coreV1.Service, policy *exposev1.ExposePolicy) *networkingV1.Ingress {
+	annotations := service.GetAnnotations()
+	f := c.resolveIngressFields(service, policy)
+
 	ig := networkingV1.Ingress{}
 	ig.Name = service.Name
 	ig.Namespace = service.Namespace
+	ig.Annotations = passthroughIngressAnnotations(annotations)
 	ig.ObjectMeta.OwnerReferences = []v1.OwnerReference{
 		*v1.NewControllerRef(service, coreV1.SchemeGroupVersion.WithKind("Service")),
 	}
-	pathType := networkingV1.PathTypePrefix
 	ig.Spec = networkingV1.IngressSpec{
 		Rules: []networkingV1.IngressRule{
 			{
-				Host: "example.com",
+				Host: f.host,
 				IngressRuleValue: networkingV1.IngressRuleValue{
 					HTTP: &networkingV1.HTTPIngressRuleValue{
 						Paths: []networkingV1.HTTPIngressPath{
 							{
-								Path:     "/",
-								PathType: &pathType,
+								Path:     f.path,
+								PathType: &f.pathType,
 								Backend: networkingV1.IngressBackend{
 									Service: &networkingV1.IngressServiceBackend{
 										Name: service.Name,
 										Port: networkingV1.ServiceBackendPort{
-											Number: 80,
+											Number: f.port,
 										},
 									},
 								},
@@ -205,5 +547,102 @@ func (c *controller) constructIngress(service *coreV1.Service) *networkingV1.Ing
 		},
 	}
 
+	if f.class != "" {
+		ig.Spec.IngressClassName = &f.class
+	}
+
+	if f.tlsSecret != "" {
+		ig.Spec.TLS = []networkingV1.IngressTLS{
+			{
+				Hosts:      []string{f.host},
+				SecretName: f.tlsSecret,
+			},
+		}
+	}
+
 	return &ig
 }
+
+// constructIngressApplyConfiguration builds the apply configuration used to
+// server-side-apply the desired Ingress for service, so the controller only
+// ever claims ownership of the fields it sets.
+func (c *controller) constructIngressApplyConfiguration(service *coreV1.Service, policy *exposev1.ExposePolicy) *networkingv1ac.IngressApplyConfiguration {
+	f := c.resolveIngressFields(service, policy)
+
+	spec := networkingv1ac.IngressSpec().
+		WithRules(networkingv1ac.IngressRule().
+			WithHost(f.host).
+			WithHTTP(networkingv1ac.HTTPIngressRuleValue().
+				WithPaths(networkingv1ac.HTTPIngressPath().
+					WithPath(f.path).
+					WithPathType(f.pathType).
+					WithBackend(networkingv1ac.IngressBackend().
+						WithService(networkingv1ac.IngressServiceBackend().
+							WithName(service.Name).
+							WithPort(networkingv1ac.ServiceBackendPort().WithNumber(f.port)))))))
+
+	if f.class != "" {
+		spec = spec.WithIngressClassName(f.class)
+	}
+	if f.tlsSecret != "" {
+		spec = spec.WithTLS(networkingv1ac.IngressTLS().WithHosts(f.host).WithSecretName(f.tlsSecret))
+	}
+
+	return networkingv1ac.Ingress(service.Name, service.Namespace).
+		WithAnnotations(passthroughIngressAnnotations(service.GetAnnotations())).
+		WithOwnerReferences(metav1ac.OwnerReference().
+			WithAPIVersion(coreV1.SchemeGroupVersion.String()).
+			WithKind("Service").
+			WithName(service.Name).
+			WithUID(service.UID).
+			WithController(true).
+			WithBlockOwnerDeletion(true)).
+		WithSpec(spec)
+}
+
+// resolvedClass picks the IngressClassName for service: the
+// ingressClassAnnotation wins, then a matching ExposePolicy's
+// IngressClassName, then the controller's own default.
+func (c *controller) resolvedClass(service *coreV1.Service, policy *exposev1.ExposePolicy) string {
+	if v, ok := service.GetAnnotations()[ingressClassAnnotation]; ok && v != "" {
+		return v
+	}
+	if policy != nil && policy.Spec.IngressClassName != "" {
+		return policy.Spec.IngressClassName
+	}
+	return c.ingressClassName
+}
+
+// classMatches reports whether this controller should manage service's
+// Ingress given the configured ingressClassName. A controller with no
+// configured class manages everything; otherwise service (directly or via a
+// matching ExposePolicy) must either ask for this class explicitly or not
+// ask for one at all.
+func (c *controller) classMatches(service *coreV1.Service, policy *exposev1.ExposePolicy) bool {
+	if c.ingressClassName == "" {
+		return true
+	}
+	requested := c.resolvedClass(service, policy)
+	if requested == "" {
+		return true
+	}
+	return requested == c.ingressClassName
+}
+
+// passthroughIngressAnnotations copies any "ingress.annotation/<key>" entry
+// from the Service onto the generated Ingress as "<key>", letting operators
+// set provider-specific directives (nginx.ingress.kubernetes.io/..., traefik
+// middlewares, ...) without the controller needing to know about them.
+func passthroughIngressAnnotations(serviceAnnotations map[string]string) map[string]string {
+	var out map[string]string
+	for k, v := range serviceAnnotations {
+		if !strings.HasPrefix(k, ingressAnnotationPrefix) {
+			continue
+		}
+		if out == nil {
+			out = map[string]string{}
+		}
+		out[strings.TrimPrefix(k, ingressAnnotationPrefix)] = v
+	}
+	return out
+}
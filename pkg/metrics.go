@@ -0,0 +1,108 @@
+package pkg
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/util/workqueue"
+)
+
+var (
+	ingressCreatedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ingress_created_total",
+		Help: "Total number of Ingresses created by the controller.",
+	})
+
+	ingressDeletedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ingress_deleted_total",
+		Help: "Total number of Ingresses deleted by the controller.",
+	})
+
+	// syncErrorsTotal is incremented in syncService with a "reason" label
+	// (e.g. "get-service", "apply", "delete") naming the failed step, so
+	// dashboards can tell a lister outage apart from an apiserver rejection.
+	syncErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sync_errors_total",
+		Help: "Total number of syncService errors, by reason.",
+	}, []string{"reason"})
+)
+
+func init() {
+	prometheus.MustRegister(ingressCreatedTotal, ingressDeletedTotal, syncErrorsTotal)
+	workqueue.SetProvider(workqueueMetricsProvider{})
+}
+
+// workqueueMetricsProvider wires the controller's workqueue into the same
+// Prometheus registry used for ingressCreatedTotal/etc., the way nginx-ingress
+// and most other controllers instrument their workqueues: depth, adds,
+// retries, processing latency and work duration are all scraped per-queue.
+type workqueueMetricsProvider struct{}
+
+func (workqueueMetricsProvider) NewDepthMetric(name string) workqueue.GaugeMetric {
+	return newWorkqueueGauge(name, "depth", "Current depth of the workqueue.")
+}
+
+func (workqueueMetricsProvider) NewAddsMetric(name string) workqueue.CounterMetric {
+	return newWorkqueueCounter(name, "adds_total", "Total number of adds handled by the workqueue.")
+}
+
+func (workqueueMetricsProvider) NewLatencyMetric(name string) workqueue.HistogramMetric {
+	return newWorkqueueHistogram(name, "queue_duration_seconds", "How long an item sits in the workqueue before being processed.")
+}
+
+func (workqueueMetricsProvider) NewWorkDurationMetric(name string) workqueue.HistogramMetric {
+	return newWorkqueueHistogram(name, "work_duration_seconds", "How long processing an item off the workqueue takes.")
+}
+
+func (workqueueMetricsProvider) NewUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return newWorkqueueGauge(name, "unfinished_work_seconds", "How long the currently in-flight item has been processed.")
+}
+
+func (workqueueMetricsProvider) NewLongestRunningProcessorSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return newWorkqueueGauge(name, "longest_running_processor_seconds", "Duration of the longest-running processor in the workqueue.")
+}
+
+func (workqueueMetricsProvider) NewRetriesMetric(name string) workqueue.CounterMetric {
+	return newWorkqueueCounter(name, "retries_total", "Total number of retries handled by the workqueue.")
+}
+
+func newWorkqueueGauge(queueName, metric, help string) prometheus.Gauge {
+	g := prometheus.NewGauge(prometheus.GaugeOpts{
+		Subsystem:   "workqueue",
+		Name:        metric,
+		Help:        help,
+		ConstLabels: prometheus.Labels{"name": queueName},
+	})
+	return mustRegisterOrExisting(g).(prometheus.Gauge)
+}
+
+func newWorkqueueCounter(queueName, metric, help string) prometheus.Counter {
+	c := prometheus.NewCounter(prometheus.CounterOpts{
+		Subsystem:   "workqueue",
+		Name:        metric,
+		Help:        help,
+		ConstLabels: prometheus.Labels{"name": queueName},
+	})
+	return mustRegisterOrExisting(c).(prometheus.Counter)
+}
+
+func newWorkqueueHistogram(queueName, metric, help string) prometheus.Histogram {
+	h := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Subsystem:   "workqueue",
+		Name:        metric,
+		Help:        help,
+		ConstLabels: prometheus.Labels{"name": queueName},
+	})
+	return mustRegisterOrExisting(h).(prometheus.Histogram)
+}
+
+// mustRegisterOrExisting registers c with the default registry, returning the
+// already-registered collector instead of panicking when the controller
+// creates a second workqueue (e.g. in tests) and the metric names collide.
+func mustRegisterOrExisting(c prometheus.Collector) prometheus.Collector {
+	if err := prometheus.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		panic(err)
+	}
+	return c
+}